@@ -4,13 +4,12 @@
 // not use this file except in compliance with the License. You may obtain
 // a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
 
-// Code generated by operationgen. DO NOT EDIT.
-
 package operation
 
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo/writeconcern"
@@ -21,161 +20,190 @@ import (
 )
 
 // CommitTransaction attempts to commit a transaction.
+//
+// Configuration is set directly on the exported fields rather than through
+// setter methods, so callers build one with a struct literal, e.g.
+// &CommitTransaction{Session: sess, Deployment: d}. A zero-valued field is
+// treated as unset and omitted from the command.
 type CommitTransaction struct {
-	maxTimeMS     *int64
-	recoveryToken bsoncore.Document
-	session       *session.Client
-	clock         *session.ClusterClock
-	monitor       *event.CommandMonitor
-	database      string
-	deployment    driver.Deployment
-	selector      description.ServerSelector
-	writeConcern  *writeconcern.WriteConcern
-	retry         *driver.RetryMode
+	MaxTimeMS     *int64
+	RecoveryToken bsoncore.Document
+	Comment       bsoncore.Value
+	Session       *session.Client
+	Clock         *session.ClusterClock
+	Monitor       *event.CommandMonitor
+	Database      string
+	Deployment    driver.Deployment
+	Selector      description.ServerSelector
+	WriteConcern  *writeconcern.WriteConcern
+	Retry         *driver.RetryMode
+	ServerAPI     *driver.ServerAPIOptions
+	Tracer        Tracer
+
+	// Retrying indicates that this call is a retry of a commit that failed
+	// with a network error. Per the transactions spec, a retried commit is
+	// not bounded by the original deadline-derived maxTimeMS, so Execute
+	// skips deriving one from ctx when Retrying is true.
+	Retrying bool
+
+	// writeConcernError and errorLabels are populated from the command
+	// response so that callers can distinguish transient commit failures
+	// (e.g. an UnknownTransactionCommitResult label) from permanent ones.
+	// Both are reset at the start of each processResponse so a retried
+	// commit never leaks the previous attempt's values.
+	writeConcernError *WriteConcernError
+	errorLabels       []string
+
+	// derivedMaxTimeMS holds the maxTimeMS value Execute computed from the
+	// context deadline, used by command only when MaxTimeMS is unset. It is
+	// recomputed (and cleared, for a retry) on every call to Execute so it
+	// never outlives the attempt it was derived for.
+	derivedMaxTimeMS *int64
 }
 
-// NewCommitTransaction constructs and returns a new CommitTransaction.
-func NewCommitTransaction() *CommitTransaction {
-	return &CommitTransaction{}
+// WriteConcernError represents a writeConcernError document returned in a
+// commitTransaction response.
+type WriteConcernError struct {
+	Name    string
+	Code    int64
+	Message string
 }
 
-func (ct *CommitTransaction) processResponse(response bsoncore.Document, srvr driver.Server, desc description.Server) error {
-	var err error
-	return err
-}
+// Error implements the error interface.
+func (wce *WriteConcernError) Error() string { return wce.Message }
 
-// Execute runs this operations and returns an error if the operaiton did not execute successfully.
-func (ct *CommitTransaction) Execute(ctx context.Context) error {
-	if ct.deployment == nil {
-		return errors.New("the CommitTransaction operation must have a Deployment set before Execute can be called")
-	}
+// WriteConcernError returns the writeConcernError reported by the server,
+// or nil if the commit did not report one.
+func (ct *CommitTransaction) WriteConcernError() *WriteConcernError { return ct.writeConcernError }
 
-	return driver.Operation{
-		CommandFn:         ct.command,
-		ProcessResponseFn: ct.processResponse,
-		RetryMode:         ct.retry,
-		RetryType:         driver.RetryWrite,
-		Client:            ct.session,
-		Clock:             ct.clock,
-		CommandMonitor:    ct.monitor,
-		Database:          ct.database,
-		Deployment:        ct.deployment,
-		Selector:          ct.selector,
-		WriteConcern:      ct.writeConcern,
-	}.Execute(ctx, nil)
+// ErrorLabels returns the errorLabels reported alongside the commit
+// response, if any.
+func (ct *CommitTransaction) ErrorLabels() []string { return ct.errorLabels }
 
-}
-
-func (ct *CommitTransaction) command(dst []byte, desc description.SelectedServer) ([]byte, error) {
+func (ct *CommitTransaction) processResponse(response bsoncore.Document, srvr driver.Server, desc description.Server) error {
+	ct.writeConcernError = nil
+	ct.errorLabels = nil
 
-	dst = bsoncore.AppendInt32Element(dst, "commitTransaction", 1)
-	if ct.maxTimeMS != nil {
-		dst = bsoncore.AppendInt64Element(dst, "maxTimeMS", *ct.maxTimeMS)
-	}
-	if ct.recoveryToken != nil {
-		dst = bsoncore.AppendDocumentElement(dst, "recoveryToken", ct.recoveryToken)
+	elements, err := response.Elements()
+	if err != nil {
+		return err
 	}
-	return dst, nil
-}
 
-// MaxTimeMS specifies the maximum amount of time to allow the query to run.
-func (ct *CommitTransaction) MaxTimeMS(maxTimeMS int64) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	for _, element := range elements {
+		switch element.Key() {
+		case "writeConcernError":
+			wceDoc, ok := element.Value().DocumentOK()
+			if !ok {
+				continue
+			}
+			wce := &WriteConcernError{}
+			wceElems, wceErr := wceDoc.Elements()
+			if wceErr != nil {
+				continue
+			}
+			for _, wceElem := range wceElems {
+				switch wceElem.Key() {
+				case "code":
+					wce.Code, _ = wceElem.Value().AsInt64OK()
+				case "codeName":
+					wce.Name, _ = wceElem.Value().StringValueOK()
+				case "errmsg":
+					wce.Message, _ = wceElem.Value().StringValueOK()
+				}
+			}
+			ct.writeConcernError = wce
+
+		case "errorLabels":
+			arr, ok := element.Value().ArrayOK()
+			if !ok {
+				continue
+			}
+			values, arrErr := arr.Values()
+			if arrErr != nil {
+				continue
+			}
+			labels := make([]string, 0, len(values))
+			for _, val := range values {
+				if label, ok := val.StringValueOK(); ok {
+					labels = append(labels, label)
+				}
+			}
+			ct.errorLabels = labels
+		}
 	}
 
-	ct.maxTimeMS = &maxTimeMS
-	return ct
+	return nil
 }
 
-// RecoveryToken sets the recovery token to use when committing or aborting a sharded transaction.
-func (ct *CommitTransaction) RecoveryToken(recoveryToken bsoncore.Document) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+// Execute runs this operations and returns an error if the operaiton did not execute successfully.
+func (ct *CommitTransaction) Execute(ctx context.Context) error {
+	if ct.Deployment == nil {
+		return errors.New("the CommitTransaction operation must have a Deployment set before Execute can be called")
 	}
 
-	ct.recoveryToken = recoveryToken
-	return ct
-}
-
-// Session sets the session for this operation.
-func (ct *CommitTransaction) Session(session *session.Client) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	ct.derivedMaxTimeMS = nil
+	if ct.MaxTimeMS == nil && !ct.Retrying {
+		if deadline, ok := ctx.Deadline(); ok {
+			ct.derivedMaxTimeMS = maxTimeMSFromDeadline(deadline)
+		}
 	}
 
-	ct.session = session
-	return ct
-}
+	ctx, span := startSpan(ctx, ct.Tracer, "commitTransaction",
+		TraceAttribute{Key: "db.system", Value: "mongodb"},
+		TraceAttribute{Key: "db.operation", Value: "commitTransaction"},
+		TraceAttribute{Key: "db.name", Value: ct.Database},
+		TraceAttribute{Key: "db.mongodb.retrying", Value: ct.Retrying},
+	)
+	defer span.End()
 
-// ClusterClock sets the cluster clock for this operation.
-func (ct *CommitTransaction) ClusterClock(clock *session.ClusterClock) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	err := driver.Operation{
+		CommandFn:         ct.command,
+		ProcessResponseFn: ct.processResponse,
+		RetryMode:         ct.Retry,
+		RetryType:         driver.RetryWrite,
+		Client:            ct.Session,
+		Clock:             ct.Clock,
+		CommandMonitor:    ct.Monitor,
+		Database:          ct.Database,
+		Deployment:        ct.Deployment,
+		Selector:          ct.Selector,
+		WriteConcern:      ct.WriteConcern,
+		ServerAPI:         ct.ServerAPI,
+	}.Execute(ctx, nil)
+	if err != nil {
+		span.SetError(err)
 	}
-
-	ct.clock = clock
-	return ct
+	return err
 }
 
-// CommandMonitor sets the monitor to use for APM events.
-func (ct *CommitTransaction) CommandMonitor(monitor *event.CommandMonitor) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
-	}
-
-	ct.monitor = monitor
-	return ct
-}
+func (ct *CommitTransaction) command(dst []byte, desc description.SelectedServer) ([]byte, error) {
 
-// Database sets the database to run this operation against.
-func (ct *CommitTransaction) Database(database string) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	dst = bsoncore.AppendInt32Element(dst, "commitTransaction", 1)
+	if ct.MaxTimeMS != nil {
+		dst = bsoncore.AppendInt64Element(dst, "maxTimeMS", *ct.MaxTimeMS)
+	} else if ct.derivedMaxTimeMS != nil {
+		dst = bsoncore.AppendInt64Element(dst, "maxTimeMS", *ct.derivedMaxTimeMS)
 	}
-
-	ct.database = database
-	return ct
-}
-
-// Deployment sets the deployment to use for this operation.
-func (ct *CommitTransaction) Deployment(deployment driver.Deployment) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	if ct.RecoveryToken != nil {
+		dst = bsoncore.AppendDocumentElement(dst, "recoveryToken", ct.RecoveryToken)
 	}
-
-	ct.deployment = deployment
-	return ct
-}
-
-// ServerSelector sets the selector used to retrieve a server.
-func (ct *CommitTransaction) ServerSelector(selector description.ServerSelector) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	if ct.Comment.Type != bsoncore.Type(0) {
+		dst = bsoncore.AppendValueElement(dst, "comment", ct.Comment)
 	}
-
-	ct.selector = selector
-	return ct
+	return dst, nil
 }
 
-// WriteConcern sets the write concern for this operation.
-func (ct *CommitTransaction) WriteConcern(writeConcern *writeconcern.WriteConcern) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+// maxTimeMSFromDeadline converts a context deadline into the maxTimeMS value
+// to send with the command, rounding up so a sub-millisecond remainder
+// isn't truncated away to zero.
+func maxTimeMSFromDeadline(deadline time.Time) *int64 {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		remaining = 0
 	}
-
-	ct.writeConcern = writeConcern
-	return ct
-}
-
-// Retry enables retryable writes for this operation. Retries are not handled automatically,
-// instead a boolean is returned from Execute and SelectAndExecute that indicates if the
-// operation can be retried. Retrying is handled by calling RetryExecute.
-func (ct *CommitTransaction) Retry(retry driver.RetryMode) *CommitTransaction {
-	if ct == nil {
-		ct = new(CommitTransaction)
+	maxTimeMS := int64(remaining / time.Millisecond)
+	if remaining%time.Millisecond > 0 {
+		maxTimeMS++
 	}
-
-	ct.retry = &retry
-	return ct
+	return &maxTimeMS
 }
@@ -4,8 +4,6 @@
 // not use this file except in compliance with the License. You may obtain
 // a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
 
-// Code generated by operationgen. DO NOT EDIT.
-
 package operation
 
 import (
@@ -23,32 +21,51 @@ import (
 )
 
 // ListDatabases performs a listDatabases operation.
+//
+// Configuration is set directly on the exported fields rather than through
+// setter methods, so callers build one with a struct literal, e.g.
+// &ListDatabases{Filter: f, Deployment: d}. A zero-valued field is treated
+// as unset and omitted from the command.
 type ListDatabases struct {
-	filter         bsoncore.Document
-	nameOnly       *bool
-	session        *session.Client
-	clock          *session.ClusterClock
-	monitor        *event.CommandMonitor
-	database       string
-	deployment     driver.Deployment
-	readPreference *readpref.ReadPref
-	retry          *driver.RetryMode
-	selector       description.ServerSelector
-
-	result ListDatabasesResult
+	Filter              bsoncore.Document
+	NameOnly            *bool
+	AuthorizedDatabases *bool
+	Comment             bsoncore.Value
+	Session             *session.Client
+	Clock               *session.ClusterClock
+	Monitor             *event.CommandMonitor
+	Database            string
+	Deployment          driver.Deployment
+	ReadPreference      *readpref.ReadPref
+	Retry               *driver.RetryMode
+	Selector            description.ServerSelector
+	ServerAPI           *driver.ServerAPIOptions
+	Tracer              Tracer
+
+	result        ListDatabasesResult
+	resultErr     error
+	resultDecoded bool
+	response      bsoncore.Document
+	srvr          driver.Server
 }
 
 type ListDatabasesResult struct {
 	// An array of documents, one document for each database
-	Databases []databaseRecord
+	Databases []DatabaseRecord
 	// The sum of the size of all the database files on disk in bytes.
 	TotalSize int64
 }
 
-type databaseRecord struct {
+// DatabaseRecord describes a single database as returned by a listDatabases
+// command, decoded by both Result and ForEach.
+type DatabaseRecord struct {
 	Name       string
 	SizeOnDisk int64 `bson:"sizeOnDisk"`
 	Empty      bool
+	// Raw holds the full per-database document as returned by the server,
+	// including any fields not otherwise surfaced above. It is reachable
+	// from both Result and ForEach now that DatabaseRecord is exported.
+	Raw bsoncore.Document
 }
 
 func buildListDatabasesResult(response bsoncore.Document, srvr driver.Server) (ListDatabasesResult, error) {
@@ -68,7 +85,6 @@ func buildListDatabasesResult(response bsoncore.Document, srvr driver.Server) (L
 			}
 
 		case "databases":
-			// TODO: Make operationgen handle array results.
 			arr, ok := element.Value().ArrayOK()
 			if !ok {
 				err = fmt.Errorf("response field 'databases' is type array, but received BSON type %s", element.Value().Type)
@@ -87,7 +103,7 @@ func buildListDatabasesResult(response bsoncore.Document, srvr driver.Server) (L
 				continue
 			}
 
-			ir.Databases = make([]databaseRecord, len(records))
+			ir.Databases = make([]DatabaseRecord, len(records))
 			for i, val := range records {
 				valueDoc, ok := val.Value().DocumentOK()
 				if !ok {
@@ -95,35 +111,10 @@ func buildListDatabasesResult(response bsoncore.Document, srvr driver.Server) (L
 					continue
 				}
 
-				elems, marshalErr := valueDoc.Elements()
-				if marshalErr != nil {
-					err = marshalErr
-					continue
-				}
-				for _, elem := range elems {
-					switch elem.Key() {
-
-					case "name":
-						ir.Databases[i].Name, ok = elem.Value().StringValueOK()
-						if !ok {
-							err = fmt.Errorf("response field 'name' is type string, but received BSON type %s", elem.Value().Type)
-							continue
-						}
-
-					case "sizeOnDisk":
-						ir.Databases[i].SizeOnDisk, ok = elem.Value().AsInt64OK()
-						if !ok {
-							err = fmt.Errorf("response field 'sizeOnDisk' is type int64, but received BSON type %s", elem.Value().Type)
-							continue
-						}
-
-					case "empty":
-						ir.Databases[i].Empty, ok = elem.Value().BooleanOK()
-						if !ok {
-							err = fmt.Errorf("response field 'empty' is type bool, but received BSON type %s", elem.Value().Type)
-							continue
-						}
-					}
+				record, recordErr := decodeDatabaseRecord(valueDoc)
+				ir.Databases[i] = record
+				if recordErr != nil {
+					err = recordErr
 				}
 			}
 		}
@@ -131,158 +122,168 @@ func buildListDatabasesResult(response bsoncore.Document, srvr driver.Server) (L
 	return ir, err
 }
 
-// NewListDatabases constructs and returns a new ListDatabases.
-func NewListDatabases(filter bsoncore.Document) *ListDatabases {
-	return &ListDatabases{
-		filter: filter,
+// decodeDatabaseRecord decodes a single element of the "databases" array
+// into a DatabaseRecord. A malformed field is recorded as an error but does
+// not stop the remaining fields of the record from being decoded; if more
+// than one field is malformed, the last error wins, matching
+// buildListDatabasesResult's handling of the top-level response fields.
+func decodeDatabaseRecord(doc bsoncore.Document) (DatabaseRecord, error) {
+	record := DatabaseRecord{Raw: doc}
+
+	elems, err := doc.Elements()
+	if err != nil {
+		return record, err
 	}
-}
 
-// Result returns the result of executing this operation.
-func (ld *ListDatabases) Result() ListDatabasesResult { return ld.result }
+	for _, elem := range elems {
+		switch elem.Key() {
 
-func (ld *ListDatabases) processResponse(response bsoncore.Document, srvr driver.Server, desc description.Server) error {
-	var err error
+		case "name":
+			name, ok := elem.Value().StringValueOK()
+			if !ok {
+				err = fmt.Errorf("response field 'name' is type string, but received BSON type %s", elem.Value().Type)
+				continue
+			}
+			record.Name = name
 
-	ld.result, err = buildListDatabasesResult(response, srvr)
-	return err
+		case "sizeOnDisk":
+			sizeOnDisk, ok := elem.Value().AsInt64OK()
+			if !ok {
+				err = fmt.Errorf("response field 'sizeOnDisk' is type int64, but received BSON type %s", elem.Value().Type)
+				continue
+			}
+			record.SizeOnDisk = sizeOnDisk
 
+		case "empty":
+			empty, ok := elem.Value().BooleanOK()
+			if !ok {
+				err = fmt.Errorf("response field 'empty' is type bool, but received BSON type %s", elem.Value().Type)
+				continue
+			}
+			record.Empty = empty
+		}
+	}
+
+	return record, err
 }
 
-// Execute runs this operations and returns an error if the operaiton did not execute successfully.
-func (ld *ListDatabases) Execute(ctx context.Context) error {
-	if ld.deployment == nil {
-		return errors.New("the ListDatabases operation must have a Deployment set before Execute can be called")
+// ForEach decodes the "databases" array of the response one element at a
+// time and invokes fn for each database record, stopping as soon as fn
+// returns a non-nil error. Unlike Result, it never materializes the full
+// slice of database records, which matters on deployments with very many
+// databases.
+func (ld *ListDatabases) ForEach(fn func(DatabaseRecord) error) error {
+	elements, err := ld.response.Elements()
+	if err != nil {
+		return err
 	}
 
-	return driver.Operation{
-		CommandFn:         ld.command,
-		ProcessResponseFn: ld.processResponse,
+	for _, element := range elements {
+		if element.Key() != "databases" {
+			continue
+		}
 
-		Client:         ld.session,
-		Clock:          ld.clock,
-		CommandMonitor: ld.monitor,
-		Database:       ld.database,
-		Deployment:     ld.deployment,
-		ReadPreference: ld.readPreference,
-		RetryMode:      ld.retry,
-		Type:           driver.Read,
-		Selector:       ld.selector,
-	}.Execute(ctx, nil)
+		arr, ok := element.Value().ArrayOK()
+		if !ok {
+			return fmt.Errorf("response field 'databases' is type array, but received BSON type %s", element.Value().Type)
+		}
 
-}
+		values, err := arr.Values()
+		if err != nil {
+			return err
+		}
 
-func (ld *ListDatabases) command(dst []byte, desc description.SelectedServer) ([]byte, error) {
-	dst = bsoncore.AppendInt32Element(dst, "listDatabases", 1)
-	if ld.filter != nil {
+		for _, val := range values {
+			valueDoc, ok := val.DocumentOK()
+			if !ok {
+				return fmt.Errorf("'databases' element is type document, but received BSON type %s", val.Type)
+			}
 
-		dst = bsoncore.AppendDocumentElement(dst, "filter", ld.filter)
-	}
-	if ld.nameOnly != nil {
+			record, err := decodeDatabaseRecord(valueDoc)
+			if err != nil {
+				return err
+			}
 
-		dst = bsoncore.AppendBooleanElement(dst, "nameOnly", *ld.nameOnly)
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
 	}
 
-	return dst, nil
+	return nil
 }
 
-// Filter determines what results are returned from listDatabases.
-func (ld *ListDatabases) Filter(filter bsoncore.Document) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+// Result returns the result of executing this operation, decoding the
+// response into a ListDatabasesResult the first time it's called. Callers
+// that only need ForEach's bounded-memory iteration never pay this decode
+// cost, since it isn't run until Result is actually invoked.
+func (ld *ListDatabases) Result() (ListDatabasesResult, error) {
+	if !ld.resultDecoded {
+		ld.result, ld.resultErr = buildListDatabasesResult(ld.response, ld.srvr)
+		ld.resultDecoded = true
 	}
-
-	ld.filter = filter
-	return ld
+	return ld.result, ld.resultErr
 }
 
-// NameOnly specifies whether to only return database names.
-func (ld *ListDatabases) NameOnly(nameOnly bool) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
-	}
-
-	ld.nameOnly = &nameOnly
-	return ld
+func (ld *ListDatabases) processResponse(response bsoncore.Document, srvr driver.Server, desc description.Server) error {
+	ld.response = response
+	ld.srvr = srvr
+	ld.resultDecoded = false
+	return nil
 }
 
-// Session sets the session for this operation.
-func (ld *ListDatabases) Session(session *session.Client) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+// Execute runs this operations and returns an error if the operaiton did not execute successfully.
+func (ld *ListDatabases) Execute(ctx context.Context) error {
+	if ld.Deployment == nil {
+		return errors.New("the ListDatabases operation must have a Deployment set before Execute can be called")
 	}
 
-	ld.session = session
-	return ld
-}
+	ctx, span := startSpan(ctx, ld.Tracer, "listDatabases",
+		TraceAttribute{Key: "db.system", Value: "mongodb"},
+		TraceAttribute{Key: "db.operation", Value: "listDatabases"},
+		TraceAttribute{Key: "db.name", Value: ld.Database},
+	)
+	defer span.End()
 
-// ClusterClock sets the cluster clock for this operation.
-func (ld *ListDatabases) ClusterClock(clock *session.ClusterClock) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
-	}
-
-	ld.clock = clock
-	return ld
-}
+	err := driver.Operation{
+		CommandFn:         ld.command,
+		ProcessResponseFn: ld.processResponse,
 
-// CommandMonitor sets the monitor to use for APM events.
-func (ld *ListDatabases) CommandMonitor(monitor *event.CommandMonitor) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+		Client:         ld.Session,
+		Clock:          ld.Clock,
+		CommandMonitor: ld.Monitor,
+		Database:       ld.Database,
+		Deployment:     ld.Deployment,
+		ReadPreference: ld.ReadPreference,
+		RetryMode:      ld.Retry,
+		Type:           driver.Read,
+		Selector:       ld.Selector,
+		ServerAPI:      ld.ServerAPI,
+	}.Execute(ctx, nil)
+	if err != nil {
+		span.SetError(err)
 	}
-
-	ld.monitor = monitor
-	return ld
+	return err
 }
 
-// Database sets the database to run this operation against.
-func (ld *ListDatabases) Database(database string) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
-	}
-
-	ld.database = database
-	return ld
-}
+func (ld *ListDatabases) command(dst []byte, desc description.SelectedServer) ([]byte, error) {
+	dst = bsoncore.AppendInt32Element(dst, "listDatabases", 1)
+	if ld.Filter != nil {
 
-// Deployment sets the deployment to use for this operation.
-func (ld *ListDatabases) Deployment(deployment driver.Deployment) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+		dst = bsoncore.AppendDocumentElement(dst, "filter", ld.Filter)
 	}
+	if ld.NameOnly != nil {
 
-	ld.deployment = deployment
-	return ld
-}
-
-// ReadPreference set the read prefernce used with this operation.
-func (ld *ListDatabases) ReadPreference(readPreference *readpref.ReadPref) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+		dst = bsoncore.AppendBooleanElement(dst, "nameOnly", *ld.NameOnly)
 	}
+	if ld.AuthorizedDatabases != nil {
 
-	ld.readPreference = readPreference
-	return ld
-}
-
-// ServerSelector sets the selector used to retrieve a server.
-func (ld *ListDatabases) ServerSelector(selector description.ServerSelector) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+		dst = bsoncore.AppendBooleanElement(dst, "authorizedDatabases", *ld.AuthorizedDatabases)
 	}
+	if ld.Comment.Type != bsoncore.Type(0) {
 
-	ld.selector = selector
-	return ld
-}
-
-// Retry enables retryable mode for this operation. Retries are handled automatically in driver.Operation.Execute based
-// on how the operation is set.
-func (ld *ListDatabases) Retry(retry driver.RetryMode) *ListDatabases {
-	if ld == nil {
-		ld = new(ListDatabases)
+		dst = bsoncore.AppendValueElement(dst, "comment", ld.Comment)
 	}
 
-	ld.retry = &retry
-	return ld
+	return dst, nil
 }
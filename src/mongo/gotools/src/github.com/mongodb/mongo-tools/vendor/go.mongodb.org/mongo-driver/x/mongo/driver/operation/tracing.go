@@ -0,0 +1,51 @@
+// Copyright (C) MongoDB, Inc. 2019-present.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+
+package operation
+
+import "context"
+
+// TraceAttribute is a single key/value pair attached to a Span.
+type TraceAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a single unit of work tracked by a Tracer. It is intentionally
+// minimal so it can be backed by go.opentelemetry.io/otel's trace.Span (or
+// any other tracing provider) without this package importing it directly.
+type Span interface {
+	// SetAttributes attaches additional attributes to the span.
+	SetAttributes(attrs ...TraceAttribute)
+	// SetError records that the traced operation failed.
+	SetError(err error)
+	// End marks the span as finished.
+	End()
+}
+
+// Tracer starts spans around operation execution. Callers that want
+// OpenTelemetry integration supply a Tracer backed by an
+// go.opentelemetry.io/otel/trace.Tracer through a small contrib shim;
+// operation itself never imports the otel packages.
+type Tracer interface {
+	StartSpan(ctx context.Context, spanName string, attrs ...TraceAttribute) (context.Context, Span)
+}
+
+// startSpan starts a span on tracer if it is non-nil, returning the
+// (possibly unmodified) context and a Span that is safe to call End on
+// even when tracer is nil.
+func startSpan(ctx context.Context, tracer Tracer, spanName string, attrs ...TraceAttribute) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.StartSpan(ctx, spanName, attrs...)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...TraceAttribute) {}
+func (noopSpan) SetError(error)                  {}
+func (noopSpan) End()                            {}